@@ -1,7 +1,12 @@
 package binassets
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path"
@@ -12,6 +17,8 @@ import (
 type asset struct {
 	path            string
 	data            []byte
+	size            int64
+	isDir           bool
 	position        int
 	assetCollection *AssetCollection
 	readDirOffset   int
@@ -26,14 +33,14 @@ func (a *asset) Name() string {
 }
 
 func (a *asset) Size() int64 {
-	if a.data == nil {
+	if a.isDir {
 		return 0
 	}
-	return int64(len(a.data))
+	return a.size
 }
 
 func (a *asset) Mode() os.FileMode {
-	if a.data == nil {
+	if a.isDir {
 		return os.ModeDir | 0444
 	}
 	return 0444
@@ -44,7 +51,7 @@ func (a *asset) Sys() interface{} {
 }
 
 func (a *asset) IsDir() bool {
-	return a.data == nil
+	return a.isDir
 }
 
 func (a *asset) ModTime() time.Time {
@@ -93,7 +100,7 @@ func (a *asset) Readdir(count int) (files []os.FileInfo, err error) {
 	basePath := pathComponents(a.path)
 	dirs := map[string]struct{}{}
 FindDirs:
-	for k, v := range *a.assetCollection {
+	for k, entry := range *a.assetCollection {
 		if k == a.path || !strings.HasPrefix(k, a.path) {
 			continue
 		}
@@ -107,12 +114,12 @@ FindDirs:
 			dirs[path.Join(components[:len(basePath)+1]...)] = struct{}{}
 			continue
 		}
-		newAsset := &asset{data: v, path: k, assetCollection: a.assetCollection}
+		newAsset := &asset{path: k, size: entry.Size, assetCollection: a.assetCollection}
 		info, _ := newAsset.Stat()
 		files = append(files, info)
 	}
 	for d := range dirs {
-		newAsset := &asset{data: nil, path: d, assetCollection: a.assetCollection}
+		newAsset := &asset{path: d, isDir: true, assetCollection: a.assetCollection}
 		info, _ := newAsset.Stat()
 		files = append(files, info)
 	}
@@ -128,21 +135,68 @@ FindDirs:
 	return
 }
 
+// AssetEntry stores a single packed asset: its bytes (gzip-compressed if
+// Compressed is set, and/or encrypted if the collection was packed with a
+// key), the original size and SHA-256 digest of the uncompressed asset, and
+// whether gzip compression was applied at pack time.
+type AssetEntry struct {
+	Data       []byte
+	Size       int64
+	SHA256     [32]byte
+	Compressed bool
+}
+
 // AssetCollection is a map containing a set of assets and implements http.FileSystem
-type AssetCollection map[string][]byte
+type AssetCollection map[string]AssetEntry
 
-// Decrypt and validate this AssetCollection witht he given key.
+// Decrypt and validate this AssetCollection witht he given key. Assets
+// encrypted with ParanoidEncrypt (Config.Paranoid) are detected
+// automatically and decrypted with ParanoidDecrypt instead.
 func (c *AssetCollection) Decrypt(key []byte) (err error) {
-	for k, v := range *c {
-		decrypted, err := Decrypt(key, v)
+	for k, entry := range *c {
+		var decrypted []byte
+		var err error
+		if IsParanoidData(entry.Data) {
+			decrypted, err = ParanoidDecrypt(key, entry.Data)
+		} else {
+			decrypted, err = Decrypt(key, entry.Data)
+		}
 		if err != nil {
 			return err
 		}
-		(*c)[k] = decrypted
+		entry.Data = decrypted
+		(*c)[k] = entry
 	}
 	return
 }
 
+// DecryptWithPassword derives the decryption key from password using kdf
+// (the KDFParams value emitted alongside this AssetCollection at pack time)
+// and decrypts and validates the collection with it.
+func (c *AssetCollection) DecryptWithPassword(password string, kdf KDFParams) (err error) {
+	return c.Decrypt(kdf.DeriveKey(password))
+}
+
+// Repair corrects bit rot in a collection packed with Config.ReedSolomon: it
+// walks every asset, decodes its Reed-Solomon codewords (correcting up to 4
+// byte errors per 136-byte codeword), and replaces Data with the repaired
+// bytes, stripping the codewords back down to the underlying (possibly still
+// encrypted and/or compressed) data. It returns the total number of byte
+// errors corrected across all assets. Call it before Decrypt,
+// DecryptWithPassword, or Open.
+func (c *AssetCollection) Repair() (repaired int, err error) {
+	for k, entry := range *c {
+		data, n, err := DecodeReedSolomon(entry.Data)
+		if err != nil {
+			return repaired, err
+		}
+		repaired += n
+		entry.Data = data
+		(*c)[k] = entry
+	}
+	return repaired, nil
+}
+
 func pathComponents(p string) (output []string) {
 	components := strings.Split(p, "/")
 	output = make([]string, 0, len(components))
@@ -154,13 +208,39 @@ func pathComponents(p string) (output []string) {
 	return
 }
 
+// resolve returns entry's plaintext data, decompressing it if Compressed
+// and validating it against entry.SHA256. It never mutates c: Open is
+// http.FileSystem.Open, which http.FileServer calls concurrently from many
+// request goroutines, and writing a decompressed copy back into the shared
+// map would race with other goroutines' reads of the same map.
+func (c AssetCollection) resolve(assetPath string, entry AssetEntry) (data []byte, err error) {
+	data = entry.Data
+	if entry.Compressed {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = ioutil.ReadAll(reader); err != nil {
+			return nil, err
+		}
+	}
+	if sha256.Sum256(data) != entry.SHA256 {
+		return nil, errors.New("binassets: asset " + assetPath + " failed SHA-256 validation")
+	}
+	return data, nil
+}
+
 // Open implements http.FileSystem.Open()
-func (c AssetCollection) Open(path string) (a http.File, err error) {
-	data, ok := c[path]
+func (c AssetCollection) Open(assetPath string) (a http.File, err error) {
+	entry, ok := c[assetPath]
 	if ok {
-		return &asset{data: data, path: path, assetCollection: &c}, nil
+		data, err := c.resolve(assetPath, entry)
+		if err != nil {
+			return nil, err
+		}
+		return &asset{data: data, size: int64(len(data)), path: assetPath, assetCollection: &c}, nil
 	}
-	basePath := pathComponents(path)
+	basePath := pathComponents(assetPath)
 FindDir:
 	for k := range c {
 		components := pathComponents(k)
@@ -172,7 +252,7 @@ FindDir:
 				continue FindDir
 			}
 		}
-		return &asset{data: nil, path: path, assetCollection: &c}, nil
+		return &asset{path: assetPath, isDir: true, assetCollection: &c}, nil
 	}
 	return nil, os.ErrNotExist
 }
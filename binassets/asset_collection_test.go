@@ -0,0 +1,55 @@
+package binassets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"sync"
+	"testing"
+)
+
+// Regression test: concurrent Open calls on the same compressed asset must
+// not race on the shared AssetCollection map the way http.FileServer drives
+// Open from many request goroutines. Run with -race to catch the
+// concurrent map write this used to trigger.
+func TestAssetCollectionOpenCompressedConcurrent(t *testing.T) {
+	plaintext := []byte("the quick brown fox jumps over the lazy dog, repeated for good gzip measure")
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(plaintext); err != nil {
+		t.Fatalf("gzip.Write: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip.Close: %v", err)
+	}
+	assets := AssetCollection{
+		"/index.html": AssetEntry{
+			Data:       buf.Bytes(),
+			Size:       int64(len(plaintext)),
+			SHA256:     sha256.Sum256(plaintext),
+			Compressed: true,
+		},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := assets.Open("/index.html")
+			if err != nil {
+				t.Errorf("Open: %v", err)
+				return
+			}
+			defer f.Close()
+			data := make([]byte, len(plaintext))
+			if _, err := f.Read(data); err != nil {
+				t.Errorf("Read: %v", err)
+				return
+			}
+			if !bytes.Equal(data, plaintext) {
+				t.Errorf("Read returned %q, want %q", data, plaintext)
+			}
+		}()
+	}
+	wg.Wait()
+}
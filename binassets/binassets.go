@@ -1,21 +1,16 @@
 package binassets
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/hmac"
+	"bytes"
+	"compress/gzip"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"path"
-	"strings"
-	"time"
 )
 
 // Config contains parameters necessary when invoking Pack()
@@ -26,8 +21,53 @@ type Config struct {
 	AssetCollection string
 	// EncryptionKey, if provided, will be used to encrypt all output data and will be
 	// required when loading assets. Use either 16, 24, or 32 bytes to select AES-128,
-	// AES-192, or AES-256.
+	// AES-192, or AES-256. Ignored if Password is set.
 	EncryptionKey []byte
+	// Password, if provided, is used in place of EncryptionKey: a key is
+	// derived from it with Argon2id at pack time using KDF (populated with
+	// DefaultKDFParams and a fresh random salt if left zero-valued). The KDF
+	// parameters are emitted alongside the AssetCollection so the same key
+	// can be re-derived with AssetCollection.DecryptWithPassword.
+	Password string
+	// KDF holds the Argon2id parameters used to derive a key from Password.
+	// Only consulted when Password is set.
+	KDF KDFParams
+	// EncryptionMode selects the on-disk format used when EncryptionKey or
+	// Password is set. Defaults to ModeCBCHMAC.
+	EncryptionMode EncryptionMode
+	// Compress gzips each asset's data before encryption. This shrinks
+	// generated files dramatically for text-heavy assets (HTML/JS/CSS);
+	// AssetCollection decompresses transparently on read.
+	Compress bool
+	// ReedSolomon wraps each asset's final bytes (after Compress and
+	// encryption, if any) in (136,128) Reed-Solomon codewords able to
+	// correct up to 4 byte errors per 128-byte chunk. Call
+	// AssetCollection.Repair before Decrypt/DecryptWithPassword/Open to
+	// correct any corruption and strip the codewords back to the
+	// underlying data.
+	ReedSolomon bool
+	// EncryptNames EME-encrypts every "/"-separated component of each
+	// asset's path, under a subkey derived from EncryptionKey (or the
+	// Password-derived key) via HKDF, so the generated .go file's
+	// AssetCollection keys don't leak the original directory tree. Requires
+	// EncryptionKey or Password to be set. Serve the result through
+	// WithNameEncryption so requests made with the original, plaintext
+	// paths still resolve. Combinable with EncryptionMode ModeAEADBlock: wrap
+	// WithNameEncryption around a NewEncryptedFileSystem of the same
+	// AssetCollection, rather than the AssetCollection directly.
+	EncryptNames bool
+	// Paranoid encrypts each asset with a cipher cascade instead of the
+	// single-pass Encrypt or EncryptAEADBlocks: AES-256-GCM under one key,
+	// then ChaCha20-Poly1305 under an independent key, both derived from
+	// EncryptionKey (or the Password-derived key) via HKDF. Recovering an
+	// asset then requires breaking both primitives. Combines with
+	// EncryptionMode: with ModeCBCHMAC (the default) each asset is sealed
+	// whole with ParanoidEncrypt, and AssetCollection.Decrypt/
+	// DecryptWithPassword detect and reverse it automatically; with
+	// ModeAEADBlock each aeadBlockSize block is sealed independently with
+	// EncryptParanoidBlocks, and NewEncryptedFileSystem detects and serves
+	// it automatically.
+	Paranoid bool
 	// OutputPath is the path the asset file will be written to. Must be a .go file.
 	OutputPath string
 	// SourcePath is the path assets will be read from
@@ -36,154 +76,6 @@ type Config struct {
 	BinAssetsPackage string
 }
 
-type asset struct {
-	path            string
-	data            []byte
-	position        int
-	assetCollection *AssetCollection
-}
-
-func (a *asset) Stat() (os.FileInfo, error) {
-	return a, nil
-}
-
-func (a *asset) Name() string {
-	return path.Base(a.path)
-}
-
-func (a *asset) Size() int64 {
-	if a.data == nil {
-		return 0
-	}
-	return int64(len(a.data))
-}
-
-func (a *asset) Mode() os.FileMode {
-	if a.data == nil {
-		return os.ModeDir | 0444
-	}
-	return 0444
-}
-
-func (a *asset) Sys() interface{} {
-	return nil
-}
-
-func (a *asset) IsDir() bool {
-	return a.data == nil
-}
-
-func (a *asset) ModTime() time.Time {
-	return time.Now()
-}
-
-func (a *asset) Close() error {
-	return nil
-}
-
-func (a *asset) Read(p []byte) (n int, err error) {
-	n = copy(p, a.data[a.position:])
-	a.position += n
-	if len(p) > 0 && n == 0 {
-		err = io.EOF
-	}
-	return
-}
-
-func (a *asset) Seek(offset int64, whence int) (n int64, err error) {
-	switch whence {
-	case 1:
-		a.position += int(offset)
-	case 2:
-		a.position = len(a.data) + int(offset)
-	default:
-		a.position = int(offset)
-	}
-	if a.position > len(a.data) {
-		a.position = len(a.data)
-	}
-	if a.position < 0 {
-		a.position = 0
-	}
-	n = int64(a.position)
-	return
-}
-
-func (a *asset) Readdir(count int) (files []os.FileInfo, err error) {
-	if !a.IsDir() {
-		return nil, nil
-	}
-	basePath := strings.Split(a.path, "/")
-	files = make([]os.FileInfo, 0)
-	dirs := map[string]struct{}{}
-FindDirs:
-	for k, v := range *a.assetCollection {
-		if k == a.path || !strings.HasPrefix(k, a.path) {
-			continue
-		}
-		components := strings.Split(k, "/")
-		for i, c := range basePath {
-			if c != components[i] {
-				continue FindDirs
-			}
-		}
-		if len(components) > len(basePath)+1 {
-			dirs[path.Join(components[:len(basePath)+1]...)] = struct{}{}
-			continue
-		}
-		newAsset := &asset{data: v, path: k, assetCollection: a.assetCollection}
-		info, _ := newAsset.Stat()
-		files = append(files, info)
-	}
-	for d := range dirs {
-		newAsset := &asset{data: nil, path: d, assetCollection: a.assetCollection}
-		info, _ := newAsset.Stat()
-		files = append(files, info)
-	}
-	return
-}
-
-// AssetCollection is a map containing a set of assets and implements http.FileSystem
-type AssetCollection map[string][]byte
-
-// Decrypt and validate this AssetCollection witht he given key.
-func (c *AssetCollection) Decrypt(key []byte) (err error) {
-	for k, v := range *c {
-		decrypted, err := Decrypt(key, v)
-		if err != nil {
-			return err
-		}
-		(*c)[k] = decrypted
-	}
-	return
-}
-
-// Open implements http.FileSystem.Open()
-func (c AssetCollection) Open(path string) (a http.File, err error) {
-	data, ok := c[path]
-	if ok {
-		return &asset{data: data, path: path, assetCollection: &c}, nil
-	}
-	if len(path) == 0 {
-		return nil, os.ErrNotExist
-	}
-	basePath := strings.Split(path, "/")
-FindDir:
-	for k := range c {
-		components := strings.Split(k, "/")
-		if len(components) != len(basePath)+1 {
-			continue
-		}
-		for i, c := range basePath {
-			if components[i] != c {
-				continue FindDir
-			}
-		}
-		return &asset{data: nil, path: path, assetCollection: &c}, nil
-	}
-	return nil, os.ErrNotExist
-}
-
 // Packer packs files based on a Config
 type Packer struct {
 	config Config
@@ -201,58 +93,56 @@ func New(config Config) *Packer {
 	return &Packer{config: config, data: AssetCollection{}}
 }
 
-// Encrypt data with the given key
-func Encrypt(key []byte, data []byte) (output []byte, err error) {
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-	padding := aes.BlockSize - (len(data) % aes.BlockSize)
-	for i := 0; i < padding; i++ {
-		data = append(data, byte(padding))
-	}
-	ciphertext := make([]byte, aes.BlockSize+len(data), aes.BlockSize+len(data)+sha256.Size)
-	iv := ciphertext[:aes.BlockSize]
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return nil, err
-	}
-	mode := cipher.NewCBCEncrypter(block, iv)
-	mode.CryptBlocks(ciphertext[aes.BlockSize:], data)
-	mac := hmac.New(sha256.New, key)
-	mac.Write(ciphertext)
-	ciphertext = append(ciphertext, mac.Sum(nil)...)
-	output = ciphertext
-	return
+// key returns the key used to encrypt asset data and, if EncryptNames is
+// set, derive the name-encryption subkey: EncryptionKey, or a key derived
+// from Password with KDF, or nil if neither is set.
+func (p *Packer) key() []byte {
+	if p.config.Password != "" {
+		return p.config.KDF.DeriveKey(p.config.Password)
+	}
+	return p.config.EncryptionKey
 }
 
-// Decrypt and validate data with the given key
-func Decrypt(key []byte, data []byte) (output []byte, err error) {
-	if len(data) < (aes.BlockSize*2+sha256.Size) || (len(data)-sha256.Size)%aes.BlockSize != 0 {
-		return nil, errors.New("Invalid data length")
-	}
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-	mac := hmac.New(sha256.New, key)
-	mac.Write(data[:len(data)-sha256.Size])
-	expectedMac := mac.Sum(nil)
-	if !hmac.Equal(expectedMac, data[len(data)-sha256.Size:]) {
-		return nil, errors.New("Invalid HMAC")
+// transform builds the AssetEntry stored for a single asset's original
+// data: it records the original size and SHA-256 digest, then optionally
+// gzips, encrypts, and/or Reed-Solomon encodes the bytes that actually get
+// written to disk.
+func (p *Packer) transform(input []byte) (entry AssetEntry, err error) {
+	entry = AssetEntry{Size: int64(len(input)), SHA256: sha256.Sum256(input)}
+	output := input
+	if p.config.Compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err = gz.Write(output); err != nil {
+			return AssetEntry{}, err
+		}
+		if err = gz.Close(); err != nil {
+			return AssetEntry{}, err
+		}
+		output = buf.Bytes()
+		entry.Compressed = true
+	}
+	key := p.key()
+	if key != nil {
+		switch {
+		case p.config.Paranoid && p.config.EncryptionMode == ModeAEADBlock:
+			output, err = EncryptParanoidBlocks(key, output)
+		case p.config.Paranoid:
+			output, err = ParanoidEncrypt(key, output)
+		case p.config.EncryptionMode == ModeAEADBlock:
+			output, err = EncryptAEADBlocks(key, output)
+		default:
+			output, err = Encrypt(key, output)
+		}
+		if err != nil {
+			return AssetEntry{}, err
+		}
 	}
-
-	iv := data[:aes.BlockSize]
-	ciphertext := data[aes.BlockSize : len(data)-sha256.Size]
-	mode := cipher.NewCBCDecrypter(block, iv)
-	mode.CryptBlocks(ciphertext, ciphertext)
-	return ciphertext[:len(ciphertext)-int(ciphertext[len(ciphertext)-1])], nil
-}
-
-func (p *Packer) transform(input []byte) (output []byte, err error) {
-	if p.config.EncryptionKey != nil {
-		return Encrypt(p.config.EncryptionKey, input)
+	if p.config.ReedSolomon {
+		output = EncodeReedSolomon(output)
 	}
-	return input, nil
+	entry.Data = output
+	return entry, nil
 }
 
 func (p *Packer) packFile(file os.FileInfo, prefix, root string) (err error) {
@@ -278,17 +168,48 @@ func (p *Packer) packFile(file os.FileInfo, prefix, root string) (err error) {
 		if err != nil {
 			return err
 		}
-		transformed, err := p.transform(data)
+		entry, err := p.transform(data)
 		if err != nil {
 			return err
 		}
-		p.data[assetPath] = transformed
+		if p.config.EncryptNames {
+			nameKey, err := deriveNameKey(p.key())
+			if err != nil {
+				return err
+			}
+			if assetPath, err = encryptPath(nameKey, assetPath); err != nil {
+				return err
+			}
+		}
+		p.data[assetPath] = entry
 	}
 	return
 }
 
 // Pack creates a packed .go file based on the assets and options specified in config.
 func (p *Packer) Pack() (err error) {
+	if p.config.EncryptNames && p.config.EncryptionKey == nil && p.config.Password == "" {
+		return errors.New("binassets: EncryptNames requires EncryptionKey or Password to be set")
+	}
+	if p.config.Password != "" && p.config.KDF.Salt == nil {
+		defaults := DefaultKDFParams()
+		if p.config.KDF.MemoryKiB == 0 {
+			p.config.KDF.MemoryKiB = defaults.MemoryKiB
+		}
+		if p.config.KDF.Iterations == 0 {
+			p.config.KDF.Iterations = defaults.Iterations
+		}
+		if p.config.KDF.Parallelism == 0 {
+			p.config.KDF.Parallelism = defaults.Parallelism
+		}
+		if p.config.KDF.KeyLen == 0 {
+			p.config.KDF.KeyLen = defaults.KeyLen
+		}
+		p.config.KDF.Salt = make([]byte, 16)
+		if _, err = rand.Read(p.config.KDF.Salt); err != nil {
+			return err
+		}
+	}
 	root, err := os.Stat(p.config.SourcePath)
 	if err != nil {
 		return err
@@ -306,6 +227,40 @@ func (p *Packer) Pack() (err error) {
 	return
 }
 
+// writeByteLiteral writes data to output as a Go []byte(“\x..”) literal.
+func writeByteLiteral(output *os.File, data []byte) (err error) {
+	if _, err = output.WriteString("[]byte(\""); err != nil {
+		return
+	}
+	h := hex.EncodeToString(data)
+	for i := 0; i < len(data); i++ {
+		if _, err = output.WriteString("\\x" + h[2*i:2*i+2]); err != nil {
+			return
+		}
+	}
+	_, err = output.WriteString("\")")
+	return
+}
+
+// writeSHA256Literal writes sum to output as a Go [32]byte{0x.., ...} literal.
+func writeSHA256Literal(output *os.File, sum [32]byte) (err error) {
+	if _, err = output.WriteString("[32]byte{"); err != nil {
+		return
+	}
+	for i, b := range sum {
+		if i > 0 {
+			if _, err = output.WriteString(", "); err != nil {
+				return
+			}
+		}
+		if _, err = output.WriteString(fmt.Sprintf("0x%02x", b)); err != nil {
+			return
+		}
+	}
+	_, err = output.WriteString("}")
+	return
+}
+
 func (p *Packer) Write() (err error) {
 	if path.Ext(p.config.OutputPath) != ".go" {
 		return errors.New("Invalid output path: " + p.config.OutputPath)
@@ -318,20 +273,38 @@ func (p *Packer) Write() (err error) {
 	if p.config.Package == "main" {
 		serverImport = "\n  \"net/http\"\n  \"flag\"\n  \"log\"\n  \"fmt\""
 	}
-	if _, err = output.WriteString(fmt.Sprintf("package %s\n\nimport (\n  \"%s\"%s\n)\n\nvar %s = binassets.AssetCollection{\n", p.config.Package, p.config.BinAssetsPackage, serverImport, p.config.AssetCollection)); err != nil {
+	if _, err = output.WriteString(fmt.Sprintf("package %s\n\nimport (\n  \"%s\"%s\n)\n\n", p.config.Package, p.config.BinAssetsPackage, serverImport)); err != nil {
 		return
 	}
-	for path, data := range p.data {
-		if _, err = output.WriteString("  \"" + path + "\": []byte(\""); err != nil {
+	if p.config.Password != "" {
+		if _, err = output.WriteString(fmt.Sprintf("var %sKDF = binassets.KDFParams{\n  Salt:        ", p.config.AssetCollection)); err != nil {
 			return
 		}
-		h := hex.EncodeToString(data)
-		for i := 0; i < len(data); i++ {
-			if _, err = output.WriteString("\\x" + h[2*i:2*i+2]); err != nil {
-				return
-			}
+		if err = writeByteLiteral(output, p.config.KDF.Salt); err != nil {
+			return
+		}
+		if _, err = output.WriteString(fmt.Sprintf(",\n  MemoryKiB:   %d,\n  Iterations:  %d,\n  Parallelism: %d,\n  KeyLen:      %d,\n}\n\n",
+			p.config.KDF.MemoryKiB, p.config.KDF.Iterations, p.config.KDF.Parallelism, p.config.KDF.KeyLen)); err != nil {
+			return
+		}
+	}
+	if _, err = output.WriteString(fmt.Sprintf("var %s = binassets.AssetCollection{\n", p.config.AssetCollection)); err != nil {
+		return
+	}
+	for path, entry := range p.data {
+		if _, err = output.WriteString(fmt.Sprintf("  \"%s\": binassets.AssetEntry{\n    Data:       ", path)); err != nil {
+			return
+		}
+		if err = writeByteLiteral(output, entry.Data); err != nil {
+			return
+		}
+		if _, err = output.WriteString(fmt.Sprintf(",\n    Size:       %d,\n    SHA256:     ", entry.Size)); err != nil {
+			return
+		}
+		if err = writeSHA256Literal(output, entry.SHA256); err != nil {
+			return
 		}
-		if _, err = output.WriteString("\"),\n"); err != nil {
+		if _, err = output.WriteString(fmt.Sprintf(",\n    Compressed: %t,\n  },\n", entry.Compressed)); err != nil {
 			return
 		}
 	}
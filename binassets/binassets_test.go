@@ -0,0 +1,57 @@
+package binassets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestSourceDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "binassets-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return dir
+}
+
+// Regression test: Pack must only fill in the KDF fields the caller left
+// zero-valued (and the Salt), not replace the whole KDF struct with
+// DefaultKDFParams, or custom --kdf-* flags would have no effect.
+func TestPackPreservesCustomKDFParams(t *testing.T) {
+	dir := newTestSourceDir(t)
+	packer := New(Config{
+		Package:    "assets",
+		Password:   "hunter2",
+		SourcePath: filepath.Join(dir, "index.html"),
+		KDF: KDFParams{
+			MemoryKiB:   1024,
+			Iterations:  1,
+			Parallelism: 1,
+		},
+	})
+	if err := packer.Pack(); err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	if packer.config.KDF.MemoryKiB != 1024 {
+		t.Errorf("MemoryKiB = %d, want 1024", packer.config.KDF.MemoryKiB)
+	}
+	if packer.config.KDF.Iterations != 1 {
+		t.Errorf("Iterations = %d, want 1", packer.config.KDF.Iterations)
+	}
+	if packer.config.KDF.Parallelism != 1 {
+		t.Errorf("Parallelism = %d, want 1", packer.config.KDF.Parallelism)
+	}
+	// KeyLen was left zero-valued, so it should fall back to the default.
+	if packer.config.KDF.KeyLen != DefaultKDFParams().KeyLen {
+		t.Errorf("KeyLen = %d, want default %d", packer.config.KDF.KeyLen, DefaultKDFParams().KeyLen)
+	}
+	if packer.config.KDF.Salt == nil {
+		t.Error("Salt was not populated")
+	}
+}
@@ -1,15 +1,80 @@
 package binassets
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
 )
 
+// EncryptionMode selects the on-disk format used when EncryptionKey or
+// Password is set.
+type EncryptionMode int
+
+const (
+	// ModeCBCHMAC is the legacy AES-CBC + HMAC-SHA256 format: Packer
+	// encrypts each asset as a single blob and AssetCollection.Decrypt (or
+	// DecryptWithPassword) must decrypt every asset into memory before any
+	// of them can be served.
+	ModeCBCHMAC EncryptionMode = iota
+	// ModeAEADBlock stores each asset as a sequence of independently
+	// sealed aeadBlockSize blocks (see EncryptAEADBlocks) and is served
+	// with NewEncryptedFileSystem, which decrypts only the blocks a
+	// request actually reads rather than the whole asset up front.
+	ModeAEADBlock
+)
+
+// aeadMagic identifies data produced by EncryptAEADBlocks.
+var aeadMagic = []byte("BINASSET")
+
+const (
+	// aeadBlockSize is the size, in plaintext bytes, of each block sealed
+	// independently by EncryptAEADBlocks.
+	aeadBlockSize = 64 * 1024
+	// aeadNonceSize is the length of the random per-file nonce stored in
+	// the header; per-block nonces are derived from it.
+	aeadNonceSize = 24
+)
+
+// KDFParams describes the Argon2id parameters and per-collection salt used
+// to derive an AES key from a user-supplied password. A value is emitted
+// alongside the packed AssetCollection so the same key can be re-derived at
+// load time with DeriveKey.
+type KDFParams struct {
+	Salt        []byte
+	MemoryKiB   uint32
+	Iterations  uint32
+	Parallelism uint8
+	KeyLen      uint32
+}
+
+// DefaultKDFParams returns the recommended Argon2id parameters: 64 MiB of
+// memory, 3 iterations, and 4 lanes of parallelism, producing a 32-byte
+// (AES-256) key. Salt is left empty and must be set before use.
+func DefaultKDFParams() KDFParams {
+	return KDFParams{
+		MemoryKiB:   64 * 1024,
+		Iterations:  3,
+		Parallelism: 4,
+		KeyLen:      32,
+	}
+}
+
+// DeriveKey runs Argon2id over password using the receiver's salt and
+// parameters, returning a key suitable for Encrypt/Decrypt.
+func (k KDFParams) DeriveKey(password string) []byte {
+	return argon2.IDKey([]byte(password), k.Salt, k.Iterations, k.MemoryKiB, k.Parallelism, k.KeyLen)
+}
+
 // Encrypt data with the given key
 func Encrypt(key []byte, data []byte) (output []byte, err error) {
 	block, err := aes.NewCipher(key)
@@ -56,3 +121,322 @@ func Decrypt(key []byte, data []byte) (output []byte, err error) {
 	mode.CryptBlocks(ciphertext, ciphertext)
 	return ciphertext[:len(ciphertext)-int(ciphertext[len(ciphertext)-1])], nil
 }
+
+func newAEADBlockCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithNonceSize(block, aeadNonceSize)
+}
+
+// aeadBlockNonce derives the nonce for block blockIndex of a file by XORing
+// its big-endian counter into the low 8 bytes of the per-file nonce, so no
+// additional nonce material needs to be stored per block.
+func aeadBlockNonce(fileNonce []byte, blockIndex uint64) []byte {
+	nonce := make([]byte, len(fileNonce))
+	copy(nonce, fileNonce)
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], blockIndex)
+	for i, b := range counter {
+		nonce[len(nonce)-8+i] ^= b
+	}
+	return nonce
+}
+
+// IsAEADBlockData reports whether data was produced by EncryptAEADBlocks.
+func IsAEADBlockData(data []byte) bool {
+	return len(data) >= len(aeadMagic) && bytes.Equal(data[:len(aeadMagic)], aeadMagic)
+}
+
+// EncryptAEADBlocks encrypts data under key as a sequence of aeadBlockSize
+// plaintext blocks, each sealed independently with AES-GCM so a reader can
+// decrypt and seek without ever materializing the whole asset in memory.
+// The output is magic || fileNonce || block_0 || block_1 || ...
+func EncryptAEADBlocks(key []byte, data []byte) (output []byte, err error) {
+	aead, err := newAEADBlockCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	fileNonce := make([]byte, aeadNonceSize)
+	if _, err = io.ReadFull(rand.Reader, fileNonce); err != nil {
+		return nil, err
+	}
+	output = append(append([]byte{}, aeadMagic...), fileNonce...)
+	for start, i := 0, uint64(0); start < len(data) || i == 0; start, i = start+aeadBlockSize, i+1 {
+		end := start + aeadBlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		output = aead.Seal(output, aeadBlockNonce(fileNonce, i), data[start:end], nil)
+		if end == len(data) {
+			break
+		}
+	}
+	return output, nil
+}
+
+// DecryptAEADBlock decrypts and validates the block at blockIndex from
+// ciphertext produced by EncryptAEADBlocks, without decoding any other
+// block.
+func DecryptAEADBlock(key []byte, ciphertext []byte, blockIndex uint64) (plaintext []byte, err error) {
+	if !IsAEADBlockData(ciphertext) || len(ciphertext) < len(aeadMagic)+aeadNonceSize {
+		return nil, errors.New("Invalid AEAD block data")
+	}
+	aead, err := newAEADBlockCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	fileNonce := ciphertext[len(aeadMagic) : len(aeadMagic)+aeadNonceSize]
+	body := ciphertext[len(aeadMagic)+aeadNonceSize:]
+	sealedBlockSize := aeadBlockSize + aead.Overhead()
+	start := int(blockIndex) * sealedBlockSize
+	if start >= len(body) {
+		return nil, io.EOF
+	}
+	end := start + sealedBlockSize
+	if end > len(body) {
+		end = len(body)
+	}
+	return aead.Open(nil, aeadBlockNonce(fileNonce, blockIndex), body[start:end], nil)
+}
+
+// aeadPlaintextSize returns the total decrypted size of ciphertext produced
+// by EncryptAEADBlocks, without decrypting any block.
+func aeadPlaintextSize(key []byte, ciphertext []byte) (int64, error) {
+	if len(ciphertext) < len(aeadMagic)+aeadNonceSize {
+		return 0, errors.New("Invalid AEAD block data")
+	}
+	aead, err := newAEADBlockCipher(key)
+	if err != nil {
+		return 0, err
+	}
+	body := ciphertext[len(aeadMagic)+aeadNonceSize:]
+	if len(body) == 0 {
+		return 0, nil
+	}
+	sealedBlockSize := aeadBlockSize + aead.Overhead()
+	numBlocks := int64((len(body) + sealedBlockSize - 1) / sealedBlockSize)
+	return int64(len(body)) - numBlocks*int64(aead.Overhead()), nil
+}
+
+// paranoidMagic identifies data produced by ParanoidEncrypt. It is chosen to
+// be the same length as, but differ early from, aeadMagic and
+// paranoidBlockMagic so IsAEADBlockData/IsParanoidData/IsParanoidBlockData
+// never false-positive on one another's output.
+var paranoidMagic = []byte("BINAPRND")
+
+// paranoidBlockMagic identifies data produced by EncryptParanoidBlocks.
+var paranoidBlockMagic = []byte("BINAPBLK")
+
+// paranoidNonceSize is the length of the single random per-asset nonce
+// ParanoidEncrypt generates and stores; the nonce used by each cascade
+// layer is derived from it, so no extra nonce bytes need to be stored.
+const paranoidNonceSize = 24
+
+// deriveParanoidKeys derives the two independent keys used by the paranoid
+// cipher cascade from the master key via HKDF-SHA256: aesKey seals the
+// inner AES-256-GCM layer, chachaKey seals the outer ChaCha20-Poly1305
+// layer.
+func deriveParanoidKeys(key []byte) (aesKey, chachaKey []byte, err error) {
+	aesKey = make([]byte, 32)
+	if _, err = io.ReadFull(hkdf.New(sha256.New, key, nil, []byte("binassets-aes-v1")), aesKey); err != nil {
+		return nil, nil, err
+	}
+	chachaKey = make([]byte, chacha20poly1305.KeySize)
+	if _, err = io.ReadFull(hkdf.New(sha256.New, key, nil, []byte("binassets-chacha-v1")), chachaKey); err != nil {
+		return nil, nil, err
+	}
+	return aesKey, chachaKey, nil
+}
+
+// paranoidNonces splits a single per-asset nonce into the independent
+// nonces used by each cascade layer via HKDF-SHA256.
+func paranoidNonces(assetNonce []byte) (aesNonce, chachaNonce []byte, err error) {
+	aesNonce = make([]byte, 12)
+	if _, err = io.ReadFull(hkdf.New(sha256.New, assetNonce, nil, []byte("binassets-aes-nonce-v1")), aesNonce); err != nil {
+		return nil, nil, err
+	}
+	chachaNonce = make([]byte, chacha20poly1305.NonceSize)
+	if _, err = io.ReadFull(hkdf.New(sha256.New, assetNonce, nil, []byte("binassets-chacha-nonce-v1")), chachaNonce); err != nil {
+		return nil, nil, err
+	}
+	return aesNonce, chachaNonce, nil
+}
+
+// IsParanoidData reports whether data was produced by ParanoidEncrypt.
+func IsParanoidData(data []byte) bool {
+	return len(data) >= len(paranoidMagic) && bytes.Equal(data[:len(paranoidMagic)], paranoidMagic)
+}
+
+// IsParanoidBlockData reports whether data was produced by
+// EncryptParanoidBlocks.
+func IsParanoidBlockData(data []byte) bool {
+	return len(data) >= len(paranoidBlockMagic) && bytes.Equal(data[:len(paranoidBlockMagic)], paranoidBlockMagic)
+}
+
+// paranoidOverhead is the number of bytes paranoidSeal adds to its input: a
+// 16-byte AES-256-GCM tag, then a 16-byte ChaCha20-Poly1305 tag.
+const paranoidOverhead = 32
+
+// paranoidSeal seals plaintext with the cipher cascade under aesKey and
+// chachaKey (see deriveParanoidKeys), deriving the nonce for each layer from
+// nonce via paranoidNonces.
+func paranoidSeal(aesKey, chachaKey, nonce, plaintext []byte) ([]byte, error) {
+	aesNonce, chachaNonce, err := paranoidNonces(nonce)
+	if err != nil {
+		return nil, err
+	}
+	aesBlock, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, err
+	}
+	aesGCM, err := cipher.NewGCM(aesBlock)
+	if err != nil {
+		return nil, err
+	}
+	inner := aesGCM.Seal(nil, aesNonce, plaintext, nil)
+	chacha, err := chacha20poly1305.New(chachaKey)
+	if err != nil {
+		return nil, err
+	}
+	return chacha.Seal(nil, chachaNonce, inner, nil), nil
+}
+
+// paranoidOpen reverses paranoidSeal, requiring both cascade layers'
+// authentication tags to verify.
+func paranoidOpen(aesKey, chachaKey, nonce, sealed []byte) ([]byte, error) {
+	aesNonce, chachaNonce, err := paranoidNonces(nonce)
+	if err != nil {
+		return nil, err
+	}
+	chacha, err := chacha20poly1305.New(chachaKey)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := chacha.Open(nil, chachaNonce, sealed, nil)
+	if err != nil {
+		return nil, err
+	}
+	aesBlock, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, err
+	}
+	aesGCM, err := cipher.NewGCM(aesBlock)
+	if err != nil {
+		return nil, err
+	}
+	return aesGCM.Open(nil, aesNonce, inner, nil)
+}
+
+// ParanoidEncrypt seals data with a cipher cascade: first AES-256-GCM under
+// an independent key K1, then the result is sealed again with
+// ChaCha20-Poly1305 under an independent key K2 (see deriveParanoidKeys),
+// so recovering data requires breaking both primitives. The output is
+// magic || assetNonce || outerCiphertext. Combine with EncryptionMode
+// ModeAEADBlock via EncryptParanoidBlocks instead, rather than sealing the
+// whole asset with this function.
+func ParanoidEncrypt(key []byte, data []byte) (output []byte, err error) {
+	aesKey, chachaKey, err := deriveParanoidKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	assetNonce := make([]byte, paranoidNonceSize)
+	if _, err = io.ReadFull(rand.Reader, assetNonce); err != nil {
+		return nil, err
+	}
+	sealed, err := paranoidSeal(aesKey, chachaKey, assetNonce, data)
+	if err != nil {
+		return nil, err
+	}
+	output = append(append([]byte{}, paranoidMagic...), assetNonce...)
+	output = append(output, sealed...)
+	return output, nil
+}
+
+// ParanoidDecrypt reverses ParanoidEncrypt, requiring both cascade layers'
+// authentication tags to verify.
+func ParanoidDecrypt(key []byte, data []byte) (output []byte, err error) {
+	if !IsParanoidData(data) || len(data) < len(paranoidMagic)+paranoidNonceSize {
+		return nil, errors.New("binassets: invalid paranoid data")
+	}
+	aesKey, chachaKey, err := deriveParanoidKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	assetNonce := data[len(paranoidMagic) : len(paranoidMagic)+paranoidNonceSize]
+	sealed := data[len(paranoidMagic)+paranoidNonceSize:]
+	return paranoidOpen(aesKey, chachaKey, assetNonce, sealed)
+}
+
+// EncryptParanoidBlocks encrypts data under key as a sequence of
+// aeadBlockSize plaintext blocks, each sealed independently with the cipher
+// cascade used by ParanoidEncrypt, so NewEncryptedFileSystem can decrypt and
+// seek without ever materializing the whole asset in memory. The output is
+// magic || fileNonce || block_0 || block_1 || ...
+func EncryptParanoidBlocks(key []byte, data []byte) (output []byte, err error) {
+	aesKey, chachaKey, err := deriveParanoidKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	fileNonce := make([]byte, paranoidNonceSize)
+	if _, err = io.ReadFull(rand.Reader, fileNonce); err != nil {
+		return nil, err
+	}
+	output = append(append([]byte{}, paranoidBlockMagic...), fileNonce...)
+	for start, i := 0, uint64(0); start < len(data) || i == 0; start, i = start+aeadBlockSize, i+1 {
+		end := start + aeadBlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		sealed, err := paranoidSeal(aesKey, chachaKey, aeadBlockNonce(fileNonce, i), data[start:end])
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, sealed...)
+		if end == len(data) {
+			break
+		}
+	}
+	return output, nil
+}
+
+// DecryptParanoidBlock decrypts and validates the block at blockIndex from
+// ciphertext produced by EncryptParanoidBlocks, without decoding any other
+// block.
+func DecryptParanoidBlock(key []byte, ciphertext []byte, blockIndex uint64) (plaintext []byte, err error) {
+	if !IsParanoidBlockData(ciphertext) || len(ciphertext) < len(paranoidBlockMagic)+paranoidNonceSize {
+		return nil, errors.New("binassets: invalid paranoid block data")
+	}
+	aesKey, chachaKey, err := deriveParanoidKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	fileNonce := ciphertext[len(paranoidBlockMagic) : len(paranoidBlockMagic)+paranoidNonceSize]
+	body := ciphertext[len(paranoidBlockMagic)+paranoidNonceSize:]
+	sealedBlockSize := aeadBlockSize + paranoidOverhead
+	start := int(blockIndex) * sealedBlockSize
+	if start >= len(body) {
+		return nil, io.EOF
+	}
+	end := start + sealedBlockSize
+	if end > len(body) {
+		end = len(body)
+	}
+	return paranoidOpen(aesKey, chachaKey, aeadBlockNonce(fileNonce, blockIndex), body[start:end])
+}
+
+// paranoidPlaintextSize returns the total decrypted size of ciphertext
+// produced by EncryptParanoidBlocks, without decrypting any block.
+func paranoidPlaintextSize(ciphertext []byte) (int64, error) {
+	if len(ciphertext) < len(paranoidBlockMagic)+paranoidNonceSize {
+		return 0, errors.New("binassets: invalid paranoid block data")
+	}
+	body := ciphertext[len(paranoidBlockMagic)+paranoidNonceSize:]
+	if len(body) == 0 {
+		return 0, nil
+	}
+	sealedBlockSize := aeadBlockSize + paranoidOverhead
+	numBlocks := int64((len(body) + sealedBlockSize - 1) / sealedBlockSize)
+	return int64(len(body)) - numBlocks*int64(paranoidOverhead), nil
+}
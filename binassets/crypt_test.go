@@ -0,0 +1,232 @@
+package binassets
+
+import (
+	"bytes"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}
+
+func TestEncryptAEADBlocksRoundTrip(t *testing.T) {
+	key := testKey(t)
+	for _, size := range []int{0, 1, aeadBlockSize - 1, aeadBlockSize, aeadBlockSize + 1, aeadBlockSize*2 + 17} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		ciphertext, err := EncryptAEADBlocks(key, data)
+		if err != nil {
+			t.Fatalf("size %d: EncryptAEADBlocks: %v", size, err)
+		}
+		if !IsAEADBlockData(ciphertext) {
+			t.Fatalf("size %d: IsAEADBlockData false for data produced by EncryptAEADBlocks", size)
+		}
+		plaintextSize, err := aeadPlaintextSize(key, ciphertext)
+		if err != nil {
+			t.Fatalf("size %d: aeadPlaintextSize: %v", size, err)
+		}
+		if plaintextSize != int64(size) {
+			t.Fatalf("size %d: aeadPlaintextSize returned %d", size, plaintextSize)
+		}
+		numBlocks := (size + aeadBlockSize - 1) / aeadBlockSize
+		if numBlocks == 0 {
+			numBlocks = 1
+		}
+		var decoded []byte
+		for i := 0; i < numBlocks; i++ {
+			block, err := DecryptAEADBlock(key, ciphertext, uint64(i))
+			if err != nil {
+				t.Fatalf("size %d: DecryptAEADBlock(%d): %v", size, i, err)
+			}
+			decoded = append(decoded, block...)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("size %d: round-tripped data mismatch", size)
+		}
+	}
+}
+
+func TestDecryptAEADBlockWrongKey(t *testing.T) {
+	key := testKey(t)
+	wrongKey := testKey(t)
+	wrongKey[0] ^= 0xff
+	ciphertext, err := EncryptAEADBlocks(key, []byte("hello, world"))
+	if err != nil {
+		t.Fatalf("EncryptAEADBlocks: %v", err)
+	}
+	if _, err := DecryptAEADBlock(wrongKey, ciphertext, 0); err == nil {
+		t.Fatal("DecryptAEADBlock succeeded with the wrong key")
+	}
+}
+
+func TestAEADBlockNonceDistinctPerBlock(t *testing.T) {
+	fileNonce := make([]byte, aeadNonceSize)
+	n0 := aeadBlockNonce(fileNonce, 0)
+	n1 := aeadBlockNonce(fileNonce, 1)
+	if bytes.Equal(n0, n1) {
+		t.Fatal("aeadBlockNonce produced the same nonce for different block indices")
+	}
+	if !bytes.Equal(n0, fileNonce) {
+		t.Fatal("aeadBlockNonce(fileNonce, 0) should leave the file nonce unchanged")
+	}
+}
+
+// Regression test: a truncated/corrupt asset that happens to start with
+// aeadMagic but is shorter than the magic+nonce header must return an error
+// from aeadPlaintextSize instead of panicking when the header is sliced.
+func TestAeadPlaintextSizeTruncatedData(t *testing.T) {
+	key := testKey(t)
+	truncated := append(append([]byte{}, aeadMagic...), make([]byte, aeadNonceSize-1)...)
+	if _, err := aeadPlaintextSize(key, truncated); err == nil {
+		t.Fatal("aeadPlaintextSize should reject data shorter than magic+nonce")
+	}
+}
+
+func TestParanoidEncryptRoundTrip(t *testing.T) {
+	key := testKey(t)
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	ciphertext, err := ParanoidEncrypt(key, data)
+	if err != nil {
+		t.Fatalf("ParanoidEncrypt: %v", err)
+	}
+	if !IsParanoidData(ciphertext) {
+		t.Fatal("IsParanoidData false for data produced by ParanoidEncrypt")
+	}
+	plaintext, err := ParanoidDecrypt(key, ciphertext)
+	if err != nil {
+		t.Fatalf("ParanoidDecrypt: %v", err)
+	}
+	if !bytes.Equal(plaintext, data) {
+		t.Fatal("ParanoidDecrypt did not recover the original data")
+	}
+}
+
+func TestParanoidDecryptTamperedData(t *testing.T) {
+	key := testKey(t)
+	ciphertext, err := ParanoidEncrypt(key, []byte("the quick brown fox"))
+	if err != nil {
+		t.Fatalf("ParanoidEncrypt: %v", err)
+	}
+	ciphertext[len(ciphertext)-1] ^= 0xff
+	if _, err := ParanoidDecrypt(key, ciphertext); err == nil {
+		t.Fatal("ParanoidDecrypt succeeded on tampered ciphertext")
+	}
+}
+
+func TestParanoidDecryptWrongKey(t *testing.T) {
+	key := testKey(t)
+	wrongKey := testKey(t)
+	wrongKey[0] ^= 0xff
+	ciphertext, err := ParanoidEncrypt(key, []byte("the quick brown fox"))
+	if err != nil {
+		t.Fatalf("ParanoidEncrypt: %v", err)
+	}
+	if _, err := ParanoidDecrypt(wrongKey, ciphertext); err == nil {
+		t.Fatal("ParanoidDecrypt succeeded with the wrong key")
+	}
+}
+
+// Regression test: aeadMagic, paranoidMagic, and paranoidBlockMagic must
+// never be a prefix of one another, or IsAEADBlockData/IsParanoidData/
+// IsParanoidBlockData false-positive on each other's output.
+func TestMagicsDoNotCollide(t *testing.T) {
+	key := testKey(t)
+	aeadData, err := EncryptAEADBlocks(key, []byte("hello"))
+	if err != nil {
+		t.Fatalf("EncryptAEADBlocks: %v", err)
+	}
+	paranoidData, err := ParanoidEncrypt(key, []byte("hello"))
+	if err != nil {
+		t.Fatalf("ParanoidEncrypt: %v", err)
+	}
+	paranoidBlockData, err := EncryptParanoidBlocks(key, []byte("hello"))
+	if err != nil {
+		t.Fatalf("EncryptParanoidBlocks: %v", err)
+	}
+	cases := []struct {
+		name string
+		data []byte
+		is   func([]byte) bool
+	}{
+		{"aead", aeadData, IsAEADBlockData},
+		{"paranoid", paranoidData, IsParanoidData},
+		{"paranoidBlock", paranoidBlockData, IsParanoidBlockData},
+	}
+	for _, c := range cases {
+		for _, other := range cases {
+			want := c.name == other.name
+			if got := other.is(c.data); got != want {
+				t.Fatalf("%s.is(%s data) = %v, want %v", other.name, c.name, got, want)
+			}
+		}
+	}
+}
+
+func TestEncryptParanoidBlocksRoundTrip(t *testing.T) {
+	key := testKey(t)
+	for _, size := range []int{0, 1, aeadBlockSize - 1, aeadBlockSize, aeadBlockSize + 1, aeadBlockSize*2 + 17} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		ciphertext, err := EncryptParanoidBlocks(key, data)
+		if err != nil {
+			t.Fatalf("size %d: EncryptParanoidBlocks: %v", size, err)
+		}
+		if !IsParanoidBlockData(ciphertext) {
+			t.Fatalf("size %d: IsParanoidBlockData false for data produced by EncryptParanoidBlocks", size)
+		}
+		plaintextSize, err := paranoidPlaintextSize(ciphertext)
+		if err != nil {
+			t.Fatalf("size %d: paranoidPlaintextSize: %v", size, err)
+		}
+		if plaintextSize != int64(size) {
+			t.Fatalf("size %d: paranoidPlaintextSize returned %d", size, plaintextSize)
+		}
+		numBlocks := (size + aeadBlockSize - 1) / aeadBlockSize
+		if numBlocks == 0 {
+			numBlocks = 1
+		}
+		var decoded []byte
+		for i := 0; i < numBlocks; i++ {
+			block, err := DecryptParanoidBlock(key, ciphertext, uint64(i))
+			if err != nil {
+				t.Fatalf("size %d: DecryptParanoidBlock(%d): %v", size, i, err)
+			}
+			decoded = append(decoded, block...)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("size %d: round-tripped data mismatch", size)
+		}
+	}
+}
+
+func TestDecryptParanoidBlockWrongKey(t *testing.T) {
+	key := testKey(t)
+	wrongKey := testKey(t)
+	wrongKey[0] ^= 0xff
+	ciphertext, err := EncryptParanoidBlocks(key, []byte("hello, world"))
+	if err != nil {
+		t.Fatalf("EncryptParanoidBlocks: %v", err)
+	}
+	if _, err := DecryptParanoidBlock(wrongKey, ciphertext, 0); err == nil {
+		t.Fatal("DecryptParanoidBlock succeeded with the wrong key")
+	}
+}
+
+// Regression test: a truncated/corrupt asset that happens to start with
+// paranoidBlockMagic but is shorter than the magic+nonce header must return
+// an error instead of panicking when the header is sliced.
+func TestParanoidPlaintextSizeTruncatedData(t *testing.T) {
+	truncated := append(append([]byte{}, paranoidBlockMagic...), make([]byte, paranoidNonceSize-1)...)
+	if _, err := paranoidPlaintextSize(truncated); err == nil {
+		t.Fatal("paranoidPlaintextSize should reject data shorter than magic+nonce")
+	}
+}
@@ -0,0 +1,173 @@
+package binassets
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+// emeBlockSize is the AES block size EME operates on.
+const emeBlockSize = 16
+
+// emeDouble multiplies a 128-bit value by x in GF(2^128), treating the block
+// as a big-endian integer (the same "doubling" used by CMAC), reducing by the
+// polynomial x^128+x^7+x^2+x+1.
+func emeDouble(in [emeBlockSize]byte) (out [emeBlockSize]byte) {
+	var carry byte
+	for i := emeBlockSize - 1; i >= 0; i-- {
+		b := in[i]
+		out[i] = (b << 1) | carry
+		carry = b >> 7
+	}
+	if carry != 0 {
+		out[emeBlockSize-1] ^= 0x87
+	}
+	return
+}
+
+func emeXor(a, b [emeBlockSize]byte) (c [emeBlockSize]byte) {
+	for i := range a {
+		c[i] = a[i] ^ b[i]
+	}
+	return
+}
+
+// emeTabulateL returns L_0..L_{m-1} where L_0 = E_K(0) and each subsequent
+// value is the previous one doubled in GF(2^128), as used to mask each
+// block in emeTransform.
+func emeTabulateL(bc cipher.Block, m int) [][emeBlockSize]byte {
+	l := make([][emeBlockSize]byte, m)
+	var zero, cur [emeBlockSize]byte
+	bc.Encrypt(cur[:], zero[:])
+	for i := 0; i < m; i++ {
+		l[i] = cur
+		cur = emeDouble(cur)
+	}
+	return l
+}
+
+// emeEncryptBlocks implements EME (ECB-Mix-ECB), the wide-block tweakable
+// mode described in Halevi and Rogaway's "A Parallelizable Enciphering
+// Mode": every output block depends on every input block, so changing one
+// byte of plaintext changes the entire ciphertext. bc must be a block
+// cipher with a 16-byte block size (AES); P must hold one or more 16-byte
+// blocks.
+func emeEncryptBlocks(bc cipher.Block, tweak [emeBlockSize]byte, P [][emeBlockSize]byte) [][emeBlockSize]byte {
+	m := len(P)
+	l := emeTabulateL(bc, m)
+	tmp := make([][emeBlockSize]byte, m)
+	for i := 0; i < m; i++ {
+		pp := emeXor(P[i], l[i])
+		bc.Encrypt(tmp[i][:], pp[:])
+	}
+	var mp [emeBlockSize]byte
+	for i := 0; i < m; i++ {
+		mp = emeXor(mp, tmp[i])
+	}
+	mp = emeXor(mp, tweak)
+	var mc [emeBlockSize]byte
+	bc.Encrypt(mc[:], mp[:])
+	mix := emeXor(mp, mc)
+
+	v := make([][emeBlockSize]byte, m)
+	v[0] = mc
+	md := mix
+	for i := 1; i < m; i++ {
+		v[i] = emeXor(tmp[i], md)
+		md = emeDouble(md)
+	}
+	C := make([][emeBlockSize]byte, m)
+	for i := 0; i < m; i++ {
+		var e [emeBlockSize]byte
+		bc.Encrypt(e[:], v[i][:])
+		C[i] = emeXor(e, l[i])
+	}
+	return C
+}
+
+// emeDecryptBlocks reverses emeEncryptBlocks.
+func emeDecryptBlocks(bc cipher.Block, tweak [emeBlockSize]byte, C [][emeBlockSize]byte) [][emeBlockSize]byte {
+	m := len(C)
+	l := emeTabulateL(bc, m)
+	v := make([][emeBlockSize]byte, m)
+	for i := 0; i < m; i++ {
+		x := emeXor(C[i], l[i])
+		bc.Decrypt(v[i][:], x[:])
+	}
+	mc := v[0]
+	var mp [emeBlockSize]byte
+	bc.Decrypt(mp[:], mc[:])
+	mix := emeXor(mp, mc)
+
+	tmp := make([][emeBlockSize]byte, m)
+	md := mix
+	var xorRest [emeBlockSize]byte
+	for i := 1; i < m; i++ {
+		tmp[i] = emeXor(v[i], md)
+		xorRest = emeXor(xorRest, tmp[i])
+		md = emeDouble(md)
+	}
+	tmp[0] = emeXor(emeXor(mp, tweak), xorRest)
+
+	P := make([][emeBlockSize]byte, m)
+	for i := 0; i < m; i++ {
+		var pp [emeBlockSize]byte
+		bc.Decrypt(pp[:], tmp[i][:])
+		P[i] = emeXor(pp, l[i])
+	}
+	return P
+}
+
+// emePad pads data with PKCS#7 padding to a multiple of emeBlockSize, so
+// arbitrary-length strings (e.g. path components) can be run through EME,
+// which only operates on whole blocks.
+func emePad(data []byte) []byte {
+	padding := emeBlockSize - (len(data) % emeBlockSize)
+	padded := make([]byte, len(data)+padding)
+	copy(padded, data)
+	for i := len(data); i < len(padded); i++ {
+		padded[i] = byte(padding)
+	}
+	return padded
+}
+
+func emeUnpad(data []byte) ([]byte, error) {
+	if len(data) == 0 || len(data)%emeBlockSize != 0 {
+		return nil, errors.New("binassets: invalid EME padding")
+	}
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > emeBlockSize || padding > len(data) {
+		return nil, errors.New("binassets: invalid EME padding")
+	}
+	return data[:len(data)-padding], nil
+}
+
+func emeToBlocks(data []byte) [][emeBlockSize]byte {
+	m := len(data) / emeBlockSize
+	blocks := make([][emeBlockSize]byte, m)
+	for i := range blocks {
+		copy(blocks[i][:], data[i*emeBlockSize:(i+1)*emeBlockSize])
+	}
+	return blocks
+}
+
+func emeFromBlocks(blocks [][emeBlockSize]byte) []byte {
+	data := make([]byte, len(blocks)*emeBlockSize)
+	for i, b := range blocks {
+		copy(data[i*emeBlockSize:], b[:])
+	}
+	return data
+}
+
+// emeEncrypt PKCS#7-pads plaintext and encrypts it under key and tweak
+// using EME.
+func emeEncrypt(bc cipher.Block, tweak [emeBlockSize]byte, plaintext []byte) []byte {
+	return emeFromBlocks(emeEncryptBlocks(bc, tweak, emeToBlocks(emePad(plaintext))))
+}
+
+// emeDecrypt reverses emeEncrypt.
+func emeDecrypt(bc cipher.Block, tweak [emeBlockSize]byte, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) == 0 || len(ciphertext)%emeBlockSize != 0 {
+		return nil, errors.New("binassets: invalid EME ciphertext length")
+	}
+	return emeUnpad(emeFromBlocks(emeDecryptBlocks(bc, tweak, emeToBlocks(ciphertext))))
+}
@@ -0,0 +1,105 @@
+package binassets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+)
+
+func emeTestCipher(t *testing.T) cipher.Block {
+	t.Helper()
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	bc, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("aes.NewCipher: %v", err)
+	}
+	return bc
+}
+
+func TestEmeEncryptRoundTrip(t *testing.T) {
+	bc := emeTestCipher(t)
+	var tweak [emeBlockSize]byte
+	for _, s := range []string{"", "a", "hello", "exactly16bytes!!", "a much longer path component that spans several blocks"} {
+		ciphertext := emeEncrypt(bc, tweak, []byte(s))
+		plaintext, err := emeDecrypt(bc, tweak, ciphertext)
+		if err != nil {
+			t.Fatalf("%q: emeDecrypt: %v", s, err)
+		}
+		if string(plaintext) != s {
+			t.Fatalf("%q: round-tripped to %q", s, plaintext)
+		}
+	}
+}
+
+func TestEmeEncryptIsWideBlock(t *testing.T) {
+	bc := emeTestCipher(t)
+	var tweak [emeBlockSize]byte
+	plaintext := []byte("this plaintext spans multiple 16-byte blocks of data")
+	c1 := emeEncrypt(bc, tweak, plaintext)
+	tampered := append([]byte{}, plaintext...)
+	tampered[0] ^= 0x01
+	c2 := emeEncrypt(bc, tweak, tampered)
+	diff := 0
+	for i := range c1 {
+		if c1[i] != c2[i] {
+			diff++
+		}
+	}
+	// EME is a wide-block mode: flipping one input byte should change most
+	// of the ciphertext, not just the block it falls in.
+	if diff < len(c1)/2 {
+		t.Fatalf("changing one plaintext byte only changed %d/%d ciphertext bytes", diff, len(c1))
+	}
+}
+
+func TestEmeDecryptRejectsBadLength(t *testing.T) {
+	bc := emeTestCipher(t)
+	var tweak [emeBlockSize]byte
+	if _, err := emeDecrypt(bc, tweak, make([]byte, emeBlockSize-1)); err == nil {
+		t.Fatal("emeDecrypt should reject ciphertext not a multiple of the block size")
+	}
+}
+
+func TestEncryptPathRoundTrip(t *testing.T) {
+	key := testKey(t)
+	nameKey, err := deriveNameKey(key)
+	if err != nil {
+		t.Fatalf("deriveNameKey: %v", err)
+	}
+	for _, p := range []string{"/index.html", "/css/style.css", "/a/b/c/d.js"} {
+		encoded, err := encryptPath(nameKey, p)
+		if err != nil {
+			t.Fatalf("%q: encryptPath: %v", p, err)
+		}
+		if encoded == p {
+			t.Fatalf("%q: encryptPath did not change the path", p)
+		}
+		decoded, err := decryptPath(nameKey, encoded)
+		if err != nil {
+			t.Fatalf("%q: decryptPath: %v", p, err)
+		}
+		if decoded != p {
+			t.Fatalf("%q: round-tripped to %q", p, decoded)
+		}
+	}
+}
+
+func TestEncryptComponentIsDeterministic(t *testing.T) {
+	key := testKey(t)
+	nameKey, err := deriveNameKey(key)
+	if err != nil {
+		t.Fatalf("deriveNameKey: %v", err)
+	}
+	block, err := newNameCipher(nameKey)
+	if err != nil {
+		t.Fatalf("newNameCipher: %v", err)
+	}
+	a := encryptComponent(block, "index.html")
+	b := encryptComponent(block, "index.html")
+	if a != b {
+		t.Fatal("encryptComponent is not deterministic, but Readdir relies on re-deriving the same ciphertext for a component encrypted independently")
+	}
+}
@@ -0,0 +1,172 @@
+package binassets
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"time"
+)
+
+// NewEncryptedFileSystem returns an http.FileSystem that transparently
+// decrypts assets packed with EncryptionMode ModeAEADBlock (optionally
+// combined with Paranoid) on demand: a Read or Seek only ever decrypts the
+// block(s) it touches, so large assets never sit fully decrypted in memory
+// the way AssetCollection.Decrypt requires. Assets stored in the legacy
+// ModeCBCHMAC format are served as-is and must already have been decrypted
+// with Decrypt or DecryptWithPassword.
+func NewEncryptedFileSystem(key []byte, c AssetCollection) http.FileSystem {
+	return &encryptedFileSystem{key: key, assets: c}
+}
+
+type encryptedFileSystem struct {
+	key    []byte
+	assets AssetCollection
+}
+
+// Open implements http.FileSystem.Open()
+func (fs *encryptedFileSystem) Open(name string) (http.File, error) {
+	entry, ok := fs.assets[name]
+	paranoid := IsParanoidBlockData(entry.Data)
+	if !ok || (!IsAEADBlockData(entry.Data) && !paranoid) {
+		return fs.assets.Open(name)
+	}
+	if !entry.Compressed {
+		return newAEADAsset(name, fs.key, entry.Data, paranoid)
+	}
+	// gzip isn't seekable, so a compressed asset can't be decoded one
+	// block at a time; fall back to decrypting and decompressing it once.
+	// NewEncryptedFileSystem's lazy-block benefit only applies to
+	// uncompressed assets.
+	sealed, err := newAEADAsset(name, fs.key, entry.Data, paranoid)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := ioutil.ReadAll(sealed)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fs.assets.resolve(name, AssetEntry{Data: plaintext, SHA256: entry.SHA256, Compressed: true})
+	if err != nil {
+		return nil, err
+	}
+	return &asset{data: data, size: int64(len(data)), path: name, assetCollection: &fs.assets}, nil
+}
+
+// aeadAsset implements http.File over data produced by EncryptAEADBlocks or
+// (if paranoid) EncryptParanoidBlocks, decrypting one aeadBlockSize block at
+// a time and caching only the most recently read block.
+type aeadAsset struct {
+	path       string
+	key        []byte
+	ciphertext []byte
+	size       int64
+	position   int64
+	blockIndex int64
+	block      []byte
+	paranoid   bool
+}
+
+func newAEADAsset(assetPath string, key []byte, ciphertext []byte, paranoid bool) (*aeadAsset, error) {
+	var size int64
+	var err error
+	if paranoid {
+		size, err = paranoidPlaintextSize(ciphertext)
+	} else {
+		size, err = aeadPlaintextSize(key, ciphertext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &aeadAsset{path: assetPath, key: key, ciphertext: ciphertext, size: size, blockIndex: -1, paranoid: paranoid}, nil
+}
+
+func (a *aeadAsset) currentBlock() ([]byte, error) {
+	index := a.position / aeadBlockSize
+	if index != a.blockIndex {
+		var block []byte
+		var err error
+		if a.paranoid {
+			block, err = DecryptParanoidBlock(a.key, a.ciphertext, uint64(index))
+		} else {
+			block, err = DecryptAEADBlock(a.key, a.ciphertext, uint64(index))
+		}
+		if err != nil {
+			return nil, err
+		}
+		a.block = block
+		a.blockIndex = index
+	}
+	return a.block, nil
+}
+
+func (a *aeadAsset) Read(p []byte) (n int, err error) {
+	if a.position >= a.size {
+		return 0, io.EOF
+	}
+	for n < len(p) && a.position < a.size {
+		block, err := a.currentBlock()
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], block[a.position%aeadBlockSize:])
+		n += copied
+		a.position += int64(copied)
+	}
+	return n, nil
+}
+
+func (a *aeadAsset) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		a.position += offset
+	case io.SeekEnd:
+		a.position = a.size + offset
+	default:
+		a.position = offset
+	}
+	if a.position < 0 {
+		a.position = 0
+	}
+	if a.position > a.size {
+		a.position = a.size
+	}
+	return a.position, nil
+}
+
+func (a *aeadAsset) Close() error {
+	return nil
+}
+
+func (a *aeadAsset) Stat() (os.FileInfo, error) {
+	return a, nil
+}
+
+func (a *aeadAsset) Name() string {
+	return path.Base(a.path)
+}
+
+func (a *aeadAsset) Size() int64 {
+	return a.size
+}
+
+func (a *aeadAsset) Mode() os.FileMode {
+	return 0444
+}
+
+func (a *aeadAsset) ModTime() time.Time {
+	return time.Now()
+}
+
+func (a *aeadAsset) IsDir() bool {
+	return false
+}
+
+func (a *aeadAsset) Sys() interface{} {
+	return nil
+}
+
+func (a *aeadAsset) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
@@ -0,0 +1,39 @@
+package binassets
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+// TestEncryptedFileSystemServesParanoidBlocks verifies that an asset packed
+// with both Paranoid and EncryptionMode ModeAEADBlock is served correctly by
+// NewEncryptedFileSystem, exercising Packer.transform's block-wise paranoid
+// sealing path end to end.
+func TestEncryptedFileSystemServesParanoidBlocks(t *testing.T) {
+	key := testKey(t)
+	plaintext := make([]byte, aeadBlockSize+17)
+	for i := range plaintext {
+		plaintext[i] = byte(i)
+	}
+	packer := New(Config{EncryptionKey: key, EncryptionMode: ModeAEADBlock, Paranoid: true})
+	entry, err := packer.transform(plaintext)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	if !IsParanoidBlockData(entry.Data) {
+		t.Fatal("Paranoid combined with ModeAEADBlock should produce EncryptParanoidBlocks output")
+	}
+	fs := NewEncryptedFileSystem(key, AssetCollection{"/data.bin": entry})
+	f, err := fs.Open("/data.bin")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(data) != string(plaintext) {
+		t.Fatal("Open served data that does not match the original asset")
+	}
+}
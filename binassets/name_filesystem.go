@@ -0,0 +1,97 @@
+package binassets
+
+import (
+	"net/http"
+	"os"
+)
+
+// WithNameEncryption returns an http.FileSystem that transparently decrypts
+// path-component names encrypted with Config.EncryptNames: Open encrypts
+// the requested path under the same subkey (derived from key via HKDF, as
+// at pack time) and looks it up against underlying's encrypted keys, and
+// Readdir decrypts the base name of every entry so http.FileServer still
+// generates correct directory listings and links for the original,
+// plaintext request URLs. underlying is typically an AssetCollection, but
+// may be any http.FileSystem over encrypted-name data, including a
+// NewEncryptedFileSystem wrapping one, to also serve assets packed with
+// EncryptionMode ModeAEADBlock.
+func WithNameEncryption(key []byte, underlying http.FileSystem) (http.FileSystem, error) {
+	nameKey, err := deriveNameKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &nameEncryptedFileSystem{nameKey: nameKey, underlying: underlying}, nil
+}
+
+type nameEncryptedFileSystem struct {
+	nameKey    []byte
+	underlying http.FileSystem
+}
+
+// Open implements http.FileSystem.Open()
+func (fs *nameEncryptedFileSystem) Open(name string) (http.File, error) {
+	encName, err := encryptPath(fs.nameKey, name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fs.underlying.Open(encName)
+	if err != nil {
+		return nil, err
+	}
+	return &nameDecryptedFile{File: f, nameKey: fs.nameKey}, nil
+}
+
+// nameDecryptedFile wraps an http.File served from an encrypted-name
+// AssetCollection, decrypting the base names Stat and Readdir report.
+type nameDecryptedFile struct {
+	http.File
+	nameKey []byte
+}
+
+func (f *nameDecryptedFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return f.decryptInfo(info)
+}
+
+func (f *nameDecryptedFile) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := f.File.Readdir(count)
+	if err != nil {
+		return infos, err
+	}
+	decrypted := make([]os.FileInfo, len(infos))
+	for i, info := range infos {
+		if decrypted[i], err = f.decryptInfo(info); err != nil {
+			return nil, err
+		}
+	}
+	return decrypted, nil
+}
+
+func (f *nameDecryptedFile) decryptInfo(info os.FileInfo) (os.FileInfo, error) {
+	// The root directory's name ("/") has no encrypted component.
+	if info.Name() == "/" {
+		return info, nil
+	}
+	block, err := newNameCipher(f.nameKey)
+	if err != nil {
+		return nil, err
+	}
+	name, err := decryptComponent(block, info.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &nameDecryptedFileInfo{FileInfo: info, name: name}, nil
+}
+
+// nameDecryptedFileInfo overrides Name() with the decrypted plaintext name.
+type nameDecryptedFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i *nameDecryptedFileInfo) Name() string {
+	return i.name
+}
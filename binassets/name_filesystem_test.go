@@ -0,0 +1,45 @@
+package binassets
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+// TestWithNameEncryptionComposesWithEncryptedFileSystem verifies that an
+// asset packed with both EncryptNames and EncryptionMode ModeAEADBlock can
+// be served by wrapping WithNameEncryption around a NewEncryptedFileSystem
+// of the same collection, using the asset's original, plaintext path.
+func TestWithNameEncryptionComposesWithEncryptedFileSystem(t *testing.T) {
+	key := testKey(t)
+	plaintext := []byte("<html>hello, world</html>")
+	packer := New(Config{EncryptionKey: key, EncryptionMode: ModeAEADBlock})
+	entry, err := packer.transform(plaintext)
+	if err != nil {
+		t.Fatalf("transform: %v", err)
+	}
+	nameKey, err := deriveNameKey(key)
+	if err != nil {
+		t.Fatalf("deriveNameKey: %v", err)
+	}
+	encName, err := encryptPath(nameKey, "/index.html")
+	if err != nil {
+		t.Fatalf("encryptPath: %v", err)
+	}
+	assets := AssetCollection{encName: entry}
+	fs, err := WithNameEncryption(key, NewEncryptedFileSystem(key, assets))
+	if err != nil {
+		t.Fatalf("WithNameEncryption: %v", err)
+	}
+	f, err := fs.Open("/index.html")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(data) != string(plaintext) {
+		t.Fatalf("Open returned %q, want %q", data, plaintext)
+	}
+}
@@ -0,0 +1,101 @@
+package binassets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base32"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// nameKeyInfo is the HKDF-SHA256 info label used to derive the EME subkey
+// used for path-component encryption from a collection's master key.
+const nameKeyInfo = "binassets-name-key-v1"
+
+// nameTweak is the fixed EME tweak used for every path component. Each
+// component is encrypted independently of its position in the path, so a
+// single component (e.g. a Readdir entry's base name) can be encrypted or
+// decrypted on its own without the rest of the path.
+var nameTweak [emeBlockSize]byte
+
+// nameBase32 is the identifier-safe, unpadded base32 alphabet used to embed
+// EME-encrypted path components inside generated .go files.
+var nameBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// deriveNameKey derives a 32-byte AES-256 subkey for path-component
+// encryption from a collection's master key via HKDF-SHA256.
+func deriveNameKey(key []byte) (nameKey []byte, err error) {
+	nameKey = make([]byte, 32)
+	if _, err = io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(nameKeyInfo)), nameKey); err != nil {
+		return nil, err
+	}
+	return nameKey, nil
+}
+
+func newNameCipher(nameKey []byte) (cipher.Block, error) {
+	return aes.NewCipher(nameKey)
+}
+
+// encryptComponent EME-encrypts a single path component under nameKey and
+// base32-encodes the result.
+func encryptComponent(block cipher.Block, component string) string {
+	return nameBase32.EncodeToString(emeEncrypt(block, nameTweak, []byte(component)))
+}
+
+// decryptComponent reverses encryptComponent.
+func decryptComponent(block cipher.Block, component string) (string, error) {
+	sealed, err := nameBase32.DecodeString(component)
+	if err != nil {
+		return "", err
+	}
+	plain, err := emeDecrypt(block, nameTweak, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// encryptPath EME-encrypts each "/"-separated component of assetPath under
+// nameKey, preserving the path's directory structure so Readdir can still
+// enumerate it level by level.
+func encryptPath(nameKey []byte, assetPath string) (string, error) {
+	block, err := newNameCipher(nameKey)
+	if err != nil {
+		return "", err
+	}
+	components := pathComponents(assetPath)
+	encoded := make([]string, len(components))
+	for i, c := range components {
+		encoded[i] = encryptComponent(block, c)
+	}
+	p := strings.Join(encoded, "/")
+	if strings.HasPrefix(assetPath, "/") {
+		p = "/" + p
+	}
+	return p, nil
+}
+
+// decryptPath reverses encryptPath.
+func decryptPath(nameKey []byte, encodedPath string) (string, error) {
+	block, err := newNameCipher(nameKey)
+	if err != nil {
+		return "", err
+	}
+	components := pathComponents(encodedPath)
+	decoded := make([]string, len(components))
+	for i, c := range components {
+		plain, err := decryptComponent(block, c)
+		if err != nil {
+			return "", err
+		}
+		decoded[i] = plain
+	}
+	p := strings.Join(decoded, "/")
+	if strings.HasPrefix(encodedPath, "/") {
+		p = "/" + p
+	}
+	return p, nil
+}
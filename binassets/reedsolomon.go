@@ -0,0 +1,331 @@
+package binassets
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// rsChunkSize is the number of data bytes protected by a single Reed-Solomon
+// codeword, and rsParitySize is the number of parity bytes added to it,
+// together forming a (136,128) code able to correct up to 4 byte errors per
+// chunk.
+const (
+	rsChunkSize    = 128
+	rsParitySize   = 8
+	rsCodewordSize = rsChunkSize + rsParitySize
+)
+
+// gfPoly is the primitive polynomial (x^8+x^4+x^3+x^2+1) used to build the
+// GF(256) exp/log tables Reed-Solomon encoding and decoding run over.
+const gfPoly = 0x11d
+
+var gfExp [512]byte
+var gfLog [256]byte
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= gfPoly
+		}
+	}
+	for i := 255; i < 512; i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+255-int(gfLog[b]))%255]
+}
+
+func gfPow(a byte, power int) byte {
+	p := (int(gfLog[a]) * power) % 255
+	if p < 0 {
+		p += 255
+	}
+	return gfExp[p]
+}
+
+func gfInverse(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// gfPolyScale and gfPolyAdd operate on polynomials stored MSB-first (index 0
+// is the highest-degree coefficient).
+func gfPolyScale(p []byte, x byte) []byte {
+	r := make([]byte, len(p))
+	for i := range p {
+		r[i] = gfMul(p[i], x)
+	}
+	return r
+}
+
+func gfPolyAdd(p, q []byte) []byte {
+	n := len(p)
+	if len(q) > n {
+		n = len(q)
+	}
+	r := make([]byte, n)
+	for i := range p {
+		r[i+n-len(p)] = p[i]
+	}
+	for i := range q {
+		r[i+n-len(q)] ^= q[i]
+	}
+	return r
+}
+
+func gfPolyMul(p, q []byte) []byte {
+	r := make([]byte, len(p)+len(q)-1)
+	for j := range q {
+		if q[j] == 0 {
+			continue
+		}
+		for i := range p {
+			r[i+j] ^= gfMul(p[i], q[j])
+		}
+	}
+	return r
+}
+
+func gfPolyEval(p []byte, x byte) byte {
+	y := p[0]
+	for i := 1; i < len(p); i++ {
+		y = gfMul(y, x) ^ p[i]
+	}
+	return y
+}
+
+func rsGeneratorPoly(nsym int) []byte {
+	g := []byte{1}
+	for i := 0; i < nsym; i++ {
+		g = gfPolyMul(g, []byte{1, gfPow(2, i)})
+	}
+	return g
+}
+
+// rsEncodeChunk appends rsParitySize Reed-Solomon parity bytes to a
+// rsChunkSize-byte chunk, returning the rsCodewordSize-byte codeword.
+func rsEncodeChunk(chunk []byte) []byte {
+	gen := rsGeneratorPoly(rsParitySize)
+	res := make([]byte, len(chunk)+rsParitySize)
+	copy(res, chunk)
+	for i := 0; i < len(chunk); i++ {
+		coef := res[i]
+		if coef != 0 {
+			for j := 1; j < len(gen); j++ {
+				res[i+j] ^= gfMul(gen[j], coef)
+			}
+		}
+	}
+	copy(res, chunk)
+	return res
+}
+
+func rsCalcSyndromes(codeword []byte) []byte {
+	synd := make([]byte, rsParitySize)
+	for i := range synd {
+		synd[i] = gfPolyEval(codeword, gfPow(2, i))
+	}
+	return synd
+}
+
+// rsFindErrorLocator runs Berlekamp-Massey over the syndromes to build the
+// error locator polynomial, failing if more errors are present than
+// rsParitySize/2 can correct.
+func rsFindErrorLocator(synd []byte) ([]byte, error) {
+	errLoc := []byte{1}
+	oldLoc := []byte{1}
+	for i := 0; i < len(synd); i++ {
+		oldLoc = append(oldLoc, 0)
+		delta := synd[i]
+		for j := 1; j < len(errLoc); j++ {
+			delta ^= gfMul(errLoc[len(errLoc)-1-j], synd[i-j])
+		}
+		if delta != 0 {
+			if len(oldLoc) > len(errLoc) {
+				newLoc := gfPolyScale(oldLoc, delta)
+				oldLoc = gfPolyScale(errLoc, gfInverse(delta))
+				errLoc = newLoc
+			}
+			errLoc = gfPolyAdd(errLoc, gfPolyScale(oldLoc, delta))
+		}
+	}
+	shift := 0
+	for shift < len(errLoc) && errLoc[shift] == 0 {
+		shift++
+	}
+	errLoc = errLoc[shift:]
+	if (len(errLoc)-1)*2 > len(synd) {
+		return nil, errors.New("binassets: too many errors to correct")
+	}
+	return errLoc, nil
+}
+
+// rsFindErrors locates the roots of errLoc via Chien search, returning the
+// byte offsets (within a codeword of length codewordLen) where errors occur.
+func rsFindErrors(errLoc []byte, codewordLen int) ([]int, error) {
+	errs := len(errLoc) - 1
+	var errPos []int
+	for j := 0; j < codewordLen; j++ {
+		degree := codewordLen - 1 - j
+		exp := (255 - degree) % 255
+		if gfPolyEval(errLoc, gfPow(2, exp)) == 0 {
+			errPos = append(errPos, j)
+		}
+	}
+	if len(errPos) != errs {
+		return nil, errors.New("binassets: could not locate errors")
+	}
+	return errPos, nil
+}
+
+// rsCorrectErrors solves for the magnitude of the error at each position in
+// errPos by Gaussian elimination over the Vandermonde system relating the
+// syndromes to the error locator values, then applies the corrections.
+func rsCorrectErrors(codeword []byte, synd []byte, errPos []int) ([]byte, error) {
+	codewordLen := len(codeword)
+	v := len(errPos)
+	x := make([]byte, v)
+	for i, p := range errPos {
+		x[i] = gfPow(2, codewordLen-1-p)
+	}
+	a := make([][]byte, v)
+	for i := 0; i < v; i++ {
+		a[i] = make([]byte, v+1)
+		for k := 0; k < v; k++ {
+			a[i][k] = gfPow(x[k], i)
+		}
+		a[i][v] = synd[i]
+	}
+	for col := 0; col < v; col++ {
+		pivot := -1
+		for row := col; row < v; row++ {
+			if a[row][col] != 0 {
+				pivot = row
+				break
+			}
+		}
+		if pivot == -1 {
+			return nil, errors.New("binassets: singular error-locator system")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		inv := gfInverse(a[col][col])
+		for k := col; k <= v; k++ {
+			a[col][k] = gfMul(a[col][k], inv)
+		}
+		for row := 0; row < v; row++ {
+			if row == col || a[row][col] == 0 {
+				continue
+			}
+			factor := a[row][col]
+			for k := col; k <= v; k++ {
+				a[row][k] ^= gfMul(factor, a[col][k])
+			}
+		}
+	}
+	corrected := append([]byte{}, codeword...)
+	for i := 0; i < v; i++ {
+		corrected[errPos[i]] ^= a[i][v]
+	}
+	return corrected, nil
+}
+
+// rsDecodeCodeword corrects up to rsParitySize/2 byte errors in a
+// rsCodewordSize-byte codeword, returning the rsChunkSize data bytes and how
+// many corrections were applied.
+func rsDecodeCodeword(codeword []byte) (chunk []byte, repaired int, err error) {
+	synd := rsCalcSyndromes(codeword)
+	clean := true
+	for _, s := range synd {
+		if s != 0 {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return codeword[:rsChunkSize], 0, nil
+	}
+	errLoc, err := rsFindErrorLocator(synd)
+	if err != nil {
+		return nil, 0, err
+	}
+	errPos, err := rsFindErrors(errLoc, len(codeword))
+	if err != nil {
+		return nil, 0, err
+	}
+	corrected, err := rsCorrectErrors(codeword, synd, errPos)
+	if err != nil {
+		return nil, 0, err
+	}
+	if verify := rsCalcSyndromes(corrected); true {
+		for _, s := range verify {
+			if s != 0 {
+				return nil, 0, errors.New("binassets: Reed-Solomon correction failed validation")
+			}
+		}
+	}
+	return corrected[:rsChunkSize], len(errPos), nil
+}
+
+// EncodeReedSolomon wraps data in a sequence of (136,128) Reed-Solomon
+// codewords, each protecting up to 4 byte errors in its 128-byte chunk. A
+// leading 8-byte big-endian length header (itself covered by the first
+// codeword) records len(data) so DecodeReedSolomon can trim the padding
+// added to the final chunk.
+func EncodeReedSolomon(data []byte) []byte {
+	body := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(body[:8], uint64(len(data)))
+	copy(body[8:], data)
+	output := make([]byte, 0, (len(body)/rsChunkSize+1)*rsCodewordSize)
+	for start := 0; start < len(body); start += rsChunkSize {
+		end := start + rsChunkSize
+		chunk := make([]byte, rsChunkSize)
+		if end > len(body) {
+			end = len(body)
+		}
+		copy(chunk, body[start:end])
+		output = append(output, rsEncodeChunk(chunk)...)
+	}
+	return output
+}
+
+// DecodeReedSolomon reverses EncodeReedSolomon, correcting up to 4 byte
+// errors per 136-byte codeword and reporting how many corrections were made
+// in total.
+func DecodeReedSolomon(data []byte) (output []byte, repaired int, err error) {
+	if len(data) == 0 || len(data)%rsCodewordSize != 0 {
+		return nil, 0, errors.New("binassets: invalid Reed-Solomon data length")
+	}
+	var body []byte
+	for start := 0; start < len(data); start += rsCodewordSize {
+		chunk, n, err := rsDecodeCodeword(data[start : start+rsCodewordSize])
+		if err != nil {
+			return nil, repaired, err
+		}
+		repaired += n
+		body = append(body, chunk...)
+	}
+	if len(body) < 8 {
+		return nil, repaired, errors.New("binassets: truncated Reed-Solomon data")
+	}
+	length := binary.BigEndian.Uint64(body[:8])
+	body = body[8:]
+	if uint64(len(body)) < length {
+		return nil, repaired, errors.New("binassets: truncated Reed-Solomon data")
+	}
+	return body[:length], repaired, nil
+}
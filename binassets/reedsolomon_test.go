@@ -0,0 +1,69 @@
+package binassets
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReedSolomonRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, rsChunkSize - 1, rsChunkSize, rsChunkSize + 5, rsChunkSize*3 + 17} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i * 7)
+		}
+		encoded := EncodeReedSolomon(data)
+		if len(encoded)%rsCodewordSize != 0 {
+			t.Fatalf("size %d: encoded length %d is not a multiple of rsCodewordSize", size, len(encoded))
+		}
+		decoded, repaired, err := DecodeReedSolomon(encoded)
+		if err != nil {
+			t.Fatalf("size %d: DecodeReedSolomon: %v", size, err)
+		}
+		if repaired != 0 {
+			t.Fatalf("size %d: expected 0 repairs on clean data, got %d", size, repaired)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("size %d: round-tripped data mismatch", size)
+		}
+	}
+}
+
+func TestReedSolomonCorrectsUpToFourByteErrors(t *testing.T) {
+	data := make([]byte, rsChunkSize*2)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	for errs := 1; errs <= 4; errs++ {
+		encoded := EncodeReedSolomon(data)
+		// Corrupt errs distinct bytes within the first codeword.
+		for i := 0; i < errs; i++ {
+			encoded[i*17] ^= 0xff
+		}
+		decoded, repaired, err := DecodeReedSolomon(encoded)
+		if err != nil {
+			t.Fatalf("%d errors: DecodeReedSolomon: %v", errs, err)
+		}
+		if repaired != errs {
+			t.Fatalf("%d errors: reported %d repairs", errs, repaired)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("%d errors: repaired data does not match original", errs)
+		}
+	}
+}
+
+func TestReedSolomonRejectsUncorrectableErrors(t *testing.T) {
+	data := make([]byte, rsChunkSize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	encoded := EncodeReedSolomon(data)
+	// Corrupt 5 distinct bytes in the first codeword: beyond the (136,128)
+	// code's 4-byte-per-codeword correction capacity.
+	for i := 0; i < 5; i++ {
+		encoded[i*13] ^= 0xff
+	}
+	if _, _, err := DecodeReedSolomon(encoded); err == nil {
+		t.Fatal("DecodeReedSolomon accepted 5 byte errors in one codeword without reporting an error")
+	}
+}
@@ -13,11 +13,37 @@ func main() {
 	flag.StringVar(&config.Package, "-package", "", "The package name to use in the generated file")
 	flag.StringVar(&config.AssetCollection, "-variable", "Assets", "Overrides name of the AssetCollection variable in the output file.")
 	var keyHex string
-	flag.StringVar(&keyHex, "-encryption-key", "", "An option encryption key, hex encoded, that will be used to encrypt all stored data. Before the resulting AssetCollection can be used, its Decrypt(key []byte) method must be called with the key passed here.")
+	flag.StringVar(&keyHex, "-encryption-key", "", "An option encryption key, hex encoded, that will be used to encrypt all stored data. Before the resulting AssetCollection can be used, its Decrypt(key []byte) method must be called with the key passed here. Ignored if --password is set.")
+	flag.StringVar(&config.Password, "-password", "", "A password to derive an encryption key from with Argon2id, in place of --encryption-key. A random salt is generated at pack time and emitted alongside the AssetCollection; load it with AssetCollection.DecryptWithPassword.")
+	defaultKDF := binassets.DefaultKDFParams()
+	var kdfMemoryKiB, kdfIterations, kdfParallelism uint
+	flag.UintVar(&kdfMemoryKiB, "-kdf-memory", uint(defaultKDF.MemoryKiB), "Argon2id memory cost in KiB to use when deriving a key from --password.")
+	flag.UintVar(&kdfIterations, "-kdf-iterations", uint(defaultKDF.Iterations), "Argon2id iteration count to use when deriving a key from --password.")
+	flag.UintVar(&kdfParallelism, "-kdf-parallelism", uint(defaultKDF.Parallelism), "Argon2id parallelism (lanes) to use when deriving a key from --password.")
+	var encryptionMode string
+	flag.StringVar(&encryptionMode, "-encryption-mode", "cbc-hmac", "The on-disk format to use when --encryption-key or --password is set: \"cbc-hmac\" (legacy, decrypt every asset up front with Decrypt/DecryptWithPassword) or \"aead-block\" (serve with NewEncryptedFileSystem, decrypting only the blocks a request reads).")
+	flag.BoolVar(&config.Compress, "-compress", false, "Gzip each asset's data before encryption and tag it with its original size and a SHA-256 digest, shrinking generated files for text-heavy assets.")
+	flag.BoolVar(&config.ReedSolomon, "-reed-solomon", false, "Wrap each asset's final bytes in (136,128) Reed-Solomon codewords able to correct up to 4 byte errors per 128-byte chunk. Call AssetCollection.Repair() to correct and strip them before Decrypt/DecryptWithPassword/Open.")
+	flag.BoolVar(&config.EncryptNames, "-encrypt-names", false, "EME-encrypt every path component of each asset's name under a subkey derived from --encryption-key or --password, so the generated file's AssetCollection keys don't leak the original directory tree. Serve the result through binassets.WithNameEncryption, wrapping it around a binassets.NewEncryptedFileSystem if --encryption-mode=aead-block is also set.")
+	flag.BoolVar(&config.Paranoid, "-paranoid", false, "Encrypt each asset with a cipher cascade (AES-256-GCM, then ChaCha20-Poly1305, under independent HKDF-derived keys) instead of the default single-pass format, at roughly 2x the cost. Combines with --encryption-mode=aead-block to seal each block of the cascade independently instead of the whole asset.")
 	flag.StringVar(&config.OutputPath, "-output", "", "The path to write to. Must be a .go file.")
 	flag.StringVar(&config.SourcePath, "-source", "", "The path to read from. Either a directory or file.")
 	flag.StringVar(&config.BinAssetsPackage, "-binassets-package", "github.com/JeremyOT/binassets", "Overrides the import path for generated files to support vendoring.")
 	flag.Parse()
+	switch encryptionMode {
+	case "cbc-hmac":
+		config.EncryptionMode = binassets.ModeCBCHMAC
+	case "aead-block":
+		config.EncryptionMode = binassets.ModeAEADBlock
+	default:
+		panic("Invalid --encryption-mode: " + encryptionMode)
+	}
+	config.KDF = binassets.KDFParams{
+		MemoryKiB:   uint32(kdfMemoryKiB),
+		Iterations:  uint32(kdfIterations),
+		Parallelism: uint8(kdfParallelism),
+		KeyLen:      defaultKDF.KeyLen,
+	}
 	if config.Package == "" {
 		panic("Missing required --package flag.")
 	}